@@ -0,0 +1,256 @@
+/*
+ * Copyright 2021 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grpc
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// ServerKeepaliveParams mirrors grpc-go's keepalive.ServerParameters: it
+// controls when the server pings an idle connection to check it's alive,
+// and when it gives up on a connection regardless of activity.
+type ServerKeepaliveParams struct {
+	// MaxConnectionIdle is the amount of time after which an idle connection
+	// (no active streams) is closed with a GOAWAY. Zero means infinite.
+	MaxConnectionIdle time.Duration
+	// MaxConnectionAge is the amount of time after which the connection is
+	// closed with a GOAWAY, regardless of activity. Zero means infinite.
+	MaxConnectionAge time.Duration
+	// MaxConnectionAgeGrace is the additional time, after MaxConnectionAge,
+	// given to finish in-flight RPCs before the connection is force-closed.
+	MaxConnectionAgeGrace time.Duration
+	// Time is the interval between keepalive pings sent on an otherwise
+	// idle connection. Zero disables pinging.
+	Time time.Duration
+	// Timeout is how long the server waits for a ping ack before closing
+	// the connection.
+	Timeout time.Duration
+}
+
+// ServerKeepaliveEnforcementPolicy mirrors grpc-go's
+// keepalive.EnforcementPolicy: it protects the server from a client that
+// pings too aggressively.
+type ServerKeepaliveEnforcementPolicy struct {
+	// MinTime is the minimum allowed interval between client pings.
+	MinTime time.Duration
+	// PermitWithoutStream allows client pings even when there are no
+	// active streams on the connection.
+	PermitWithoutStream bool
+}
+
+// WithKeepaliveParams sets the connection's keepalive ping/idle/age
+// behaviour.
+func WithKeepaliveParams(p ServerKeepaliveParams) ServerTransportOption {
+	return func(o *options) {
+		o.keepaliveParams = p
+	}
+}
+
+// WithKeepaliveEnforcementPolicy sets the policy used to police how
+// aggressively a client may ping the connection.
+func WithKeepaliveEnforcementPolicy(p ServerKeepaliveEnforcementPolicy) ServerTransportOption {
+	return func(o *options) {
+		o.enforcementPolicy = p
+	}
+}
+
+// keepaliveServer runs a ServerTransport's keepalive ping, idle and age
+// timers, and enforces the misbehaving-ping policy, mirroring the algorithm
+// in grpc-go's internal/transport http2_server.go.
+type keepaliveServer struct {
+	t      *ServerTransport
+	params ServerKeepaliveParams
+	policy ServerKeepaliveEnforcementPolicy
+
+	stopCh chan struct{}
+
+	mu           sync.Mutex
+	lastActivity time.Time
+	awaitingAck  bool
+
+	lastPing  time.Time
+	pingCount int
+}
+
+func newKeepaliveServer(t *ServerTransport, params ServerKeepaliveParams, policy ServerKeepaliveEnforcementPolicy) *keepaliveServer {
+	return &keepaliveServer{
+		t:            t,
+		params:       params,
+		policy:       policy,
+		stopCh:       make(chan struct{}),
+		lastActivity: time.Now(),
+	}
+}
+
+func (k *keepaliveServer) start() {
+	if k.params == (ServerKeepaliveParams{}) {
+		return
+	}
+	go k.ageLoop()
+	if k.params.Time > 0 {
+		go k.pingLoop()
+	}
+}
+
+func (k *keepaliveServer) stop() {
+	select {
+	case <-k.stopCh:
+	default:
+		close(k.stopCh)
+	}
+}
+
+// onFrame records that the connection saw activity, for MaxConnectionIdle.
+func (k *keepaliveServer) onFrame() {
+	k.mu.Lock()
+	k.lastActivity = time.Now()
+	k.mu.Unlock()
+}
+
+// onPingAck clears the outstanding ping, so the timeout timer in pingLoop
+// doesn't close the connection.
+func (k *keepaliveServer) onPingAck() {
+	k.mu.Lock()
+	k.awaitingAck = false
+	k.mu.Unlock()
+}
+
+// checkClientPing applies the enforcement policy to a client-initiated
+// ping, reporting whether the connection should stay open. hasStreams is
+// whether the connection currently has any active streams.
+func (k *keepaliveServer) checkClientPing(hasStreams bool) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !hasStreams && !k.policy.PermitWithoutStream {
+		return false
+	}
+	now := time.Now()
+	if k.policy.MinTime > 0 && !k.lastPing.IsZero() && now.Sub(k.lastPing) < k.policy.MinTime {
+		k.pingCount++
+		k.lastPing = now
+		// grpc-go tolerates a couple of too-fast pings (e.g. a client
+		// pipelining two RPCs) before treating it as abuse.
+		return k.pingCount <= 2
+	}
+	k.pingCount = 0
+	k.lastPing = now
+	return true
+}
+
+func (k *keepaliveServer) ageLoop() {
+	age := k.params.MaxConnectionAge
+	if age <= 0 {
+		age = math.MaxInt64
+	}
+	idle := k.params.MaxConnectionIdle
+	if idle <= 0 {
+		idle = math.MaxInt64
+	}
+	start := time.Now()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-k.stopCh:
+			return
+		case <-ticker.C:
+			if time.Since(start) >= age {
+				k.closeWithGrace()
+				return
+			}
+			// MaxConnectionIdle only applies once the connection has no
+			// active streams, matching grpc-go: a server-streaming RPC
+			// whose client goes quiet after its one request is not idle,
+			// even though no further frames arrive from it.
+			k.t.mu.Lock()
+			hasStreams := len(k.t.streams) > 0
+			k.t.mu.Unlock()
+			if hasStreams {
+				continue
+			}
+			k.mu.Lock()
+			idleFor := time.Since(k.lastActivity)
+			k.mu.Unlock()
+			if idleFor >= idle {
+				k.closeWithGrace()
+				return
+			}
+		}
+	}
+}
+
+func (k *keepaliveServer) closeWithGrace() {
+	k.t.writeMu.Lock()
+	k.t.framer.WriteGoAway(math.MaxUint32, http2.ErrCodeNo, nil)
+	k.t.writeMu.Unlock()
+	if k.params.MaxConnectionAgeGrace > 0 {
+		time.AfterFunc(k.params.MaxConnectionAgeGrace, func() { k.t.Close() })
+		return
+	}
+	k.t.Close()
+}
+
+func (k *keepaliveServer) pingLoop() {
+	ticker := time.NewTicker(k.params.Time)
+	defer ticker.Stop()
+	timeout := k.params.Timeout
+	if timeout <= 0 {
+		timeout = 20 * time.Second
+	}
+	for {
+		select {
+		case <-k.stopCh:
+			return
+		case <-ticker.C:
+			k.mu.Lock()
+			if time.Since(k.lastActivity) < k.params.Time {
+				// the connection saw other frames recently; it isn't idle.
+				k.mu.Unlock()
+				continue
+			}
+			k.awaitingAck = true
+			k.mu.Unlock()
+			k.t.writeMu.Lock()
+			err := k.t.framer.WritePing(false, [8]byte{})
+			k.t.writeMu.Unlock()
+			if err != nil {
+				k.t.Close()
+				return
+			}
+			select {
+			case <-time.After(timeout):
+				k.mu.Lock()
+				stillWaiting := k.awaitingAck
+				k.mu.Unlock()
+				if stillWaiting {
+					// the client never acked the keepalive ping in time.
+					k.t.writeMu.Lock()
+					k.t.framer.WriteGoAway(math.MaxUint32, http2.ErrCodeEnhanceYourCalm, nil)
+					k.t.writeMu.Unlock()
+					k.t.Close()
+					return
+				}
+			case <-k.stopCh:
+				return
+			}
+		}
+	}
+}
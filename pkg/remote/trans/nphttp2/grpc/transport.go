@@ -0,0 +1,475 @@
+/*
+ * Copyright 2021 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package grpc is the HTTP/2 server transport that backs nphttp2: it speaks
+// the gRPC wire format (length-prefixed messages over HTTP/2 DATA frames)
+// and, when enabled, the gRPC-Web wire format (the same DATA frames, but
+// with trailers sent as a trailing 0x80-prefixed frame instead of HTTP/2
+// trailers) over a plain HTTP/1.1 connection.
+package grpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/cloudwego/netpoll"
+
+	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/status"
+)
+
+// gRPC-Web content types for the HTTP/1.1 response head, mirroring the
+// Content-Type nphttp2's OnRead sniffed on the request (see grpcweb.go).
+const (
+	contentTypeGRPCWebProto = "application/grpc-web+proto"
+	contentTypeGRPCWebText  = "application/grpc-web-text"
+)
+
+// Infinity disables netpoll's read timeout. The transport relies on its own
+// keepalive logic (see keepalive.go), not a read deadline, to notice a dead
+// peer, matching grpc-go's ServerTransport.
+const Infinity time.Duration = 0
+
+// options collects everything a ServerTransportOption can configure.
+type options struct {
+	grpcWeb           bool
+	grpcWebText       bool
+	keepaliveParams   ServerKeepaliveParams
+	enforcementPolicy ServerKeepaliveEnforcementPolicy
+}
+
+// ServerTransportOption configures a ServerTransport built by
+// NewServerTransport.
+type ServerTransportOption func(*options)
+
+// WithGRPCWeb makes the transport read the connection as an HTTP/1.1
+// gRPC-Web request instead of native HTTP/2, and send trailers as a
+// trailing 0x80-prefixed frame in the response body instead of HTTP/2
+// trailers. text selects the base64-encoded "-text" wire variant.
+func WithGRPCWeb(text bool) ServerTransportOption {
+	return func(o *options) {
+		o.grpcWeb = true
+		o.grpcWebText = text
+	}
+}
+
+// ServerTransport serves a single HTTP/2 (or, with WithGRPCWeb, HTTP/1.1
+// gRPC-Web) connection, multiplexing its streams.
+type ServerTransport struct {
+	conn   netpoll.Connection
+	opts   options
+	framer *http2.Framer
+	enc    *hpack.Encoder
+	encBuf *bytes.Buffer
+
+	mu      sync.Mutex
+	streams map[uint32]*Stream
+	writeMu sync.Mutex
+
+	kp *keepaliveServer
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewServerTransport performs the connection preface/handshake on conn and
+// returns a ServerTransport ready to have HandleStreams called on it.
+func NewServerTransport(ctx context.Context, conn netpoll.Connection, opts ...ServerTransportOption) (*ServerTransport, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !o.grpcWeb {
+		// consume the client connection preface; grpc-web connections are
+		// plain HTTP/1.1 and have none.
+		pref := http2.ClientPreface
+		buf := make([]byte, len(pref))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, fmt.Errorf("nphttp2/grpc: reading client preface: %w", err)
+		}
+		if string(buf) != pref {
+			return nil, fmt.Errorf("nphttp2/grpc: invalid client preface %q", buf)
+		}
+	}
+
+	encBuf := &bytes.Buffer{}
+	t := &ServerTransport{
+		conn:    conn,
+		opts:    o,
+		framer:  http2.NewFramer(conn, conn),
+		enc:     hpack.NewEncoder(encBuf),
+		encBuf:  encBuf,
+		streams: make(map[uint32]*Stream),
+		closed:  make(chan struct{}),
+	}
+	if !o.grpcWeb {
+		if err := t.framer.WriteSettings(); err != nil {
+			return nil, err
+		}
+	}
+	t.kp = newKeepaliveServer(t, o.keepaliveParams, o.enforcementPolicy)
+	t.kp.start()
+	return t, nil
+}
+
+// RemoteAddr returns the address of the connected peer.
+func (t *ServerTransport) RemoteAddr() net.Addr {
+	return t.conn.RemoteAddr()
+}
+
+// Close tears down the connection and stops the keepalive goroutine.
+func (t *ServerTransport) Close() error {
+	t.closeOnce.Do(func() {
+		t.kp.stop()
+		close(t.closed)
+	})
+	return t.conn.Close()
+}
+
+// HandleStreams reads frames off the connection until it closes, invoking
+// handler for every stream the client opens; setHeader is called to attach
+// per-RPC context values (e.g. from the HTTP/2 :path) before handler runs.
+func (t *ServerTransport) HandleStreams(handler func(*Stream), setHeader func(ctx context.Context, method string) context.Context) {
+	if t.opts.grpcWeb {
+		t.handleGRPCWebStream(handler, setHeader)
+		return
+	}
+	var hdec *hpack.Decoder
+	var curStream *Stream
+	hdec = hpack.NewDecoder(4096, func(f hpack.HeaderField) {
+		if curStream == nil {
+			return
+		}
+		if f.Name == ":path" {
+			curStream.method = f.Value
+		}
+	})
+	for {
+		f, err := t.framer.ReadFrame()
+		if err != nil {
+			t.Close()
+			return
+		}
+		if _, isPing := f.(*http2.PingFrame); !isPing {
+			// PINGs and their acks don't count as activity, or the server's
+			// own keepalive pings would keep an idle connection open.
+			t.kp.onFrame()
+		}
+		switch f := f.(type) {
+		case *http2.HeadersFrame:
+			ctx := setHeader(context.Background(), "")
+			s := newStream(t, f.StreamID, ctx)
+			curStream = s
+			if _, err := hdec.Write(f.HeaderBlockFragment()); err != nil {
+				t.Close()
+				return
+			}
+			s.ctx = setHeader(context.Background(), s.method)
+			t.mu.Lock()
+			t.streams[f.StreamID] = s
+			t.mu.Unlock()
+			handler(s)
+		case *http2.DataFrame:
+			t.mu.Lock()
+			s := t.streams[f.StreamID]
+			t.mu.Unlock()
+			if s == nil {
+				continue
+			}
+			data := append([]byte(nil), f.Data()...)
+			select {
+			case s.reads <- data:
+			case <-s.done():
+			}
+			if f.StreamEnded() {
+				close(s.reads)
+			}
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				t.mu.Lock()
+				hasStreams := len(t.streams) > 0
+				t.mu.Unlock()
+				if !t.kp.checkClientPing(hasStreams) {
+					t.writeMu.Lock()
+					t.framer.WriteGoAway(math.MaxUint32, http2.ErrCodeEnhanceYourCalm, nil)
+					t.writeMu.Unlock()
+					t.Close()
+					return
+				}
+				t.writeMu.Lock()
+				t.framer.WritePing(true, f.Data)
+				t.writeMu.Unlock()
+			} else {
+				t.kp.onPingAck()
+			}
+		case *http2.RSTStreamFrame:
+			t.mu.Lock()
+			delete(t.streams, f.StreamID)
+			t.mu.Unlock()
+		case *http2.GoAwayFrame:
+			t.Close()
+			return
+		}
+	}
+}
+
+// handleGRPCWebStream handles the simpler HTTP/1.1 gRPC-Web case: a single
+// request/response pair per connection, no multiplexing.
+func (t *ServerTransport) handleGRPCWebStream(handler func(*Stream), setHeader func(ctx context.Context, method string) context.Context) {
+	req, err := http.ReadRequest(bufio.NewReader(t.conn))
+	if err != nil {
+		t.Close()
+		return
+	}
+
+	contentType := contentTypeGRPCWebProto
+	if t.opts.grpcWebText {
+		contentType = contentTypeGRPCWebText
+	}
+	head := "HTTP/1.1 200 OK\r\nContent-Type: " + contentType + "\r\n\r\n"
+	t.writeMu.Lock()
+	_, err = t.conn.Write([]byte(head))
+	t.writeMu.Unlock()
+	if err != nil {
+		t.Close()
+		return
+	}
+
+	ctx := setHeader(context.Background(), req.URL.Path)
+	s := newStream(t, 0, ctx)
+	s.method = req.URL.Path
+	var body io.Reader = req.Body
+	if t.opts.grpcWebText {
+		// the grpc-web-text wire format is the whole request body, frames
+		// and all, encoded as one continuous base64 stream.
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	go func() {
+		defer req.Body.Close()
+		buf := make([]byte, 4096)
+		for {
+			n, err := body.Read(buf)
+			if n > 0 {
+				data := append([]byte(nil), buf[:n]...)
+				select {
+				case s.reads <- data:
+				case <-s.done():
+					return
+				}
+			}
+			if err != nil {
+				close(s.reads)
+				return
+			}
+		}
+	}()
+	handler(s)
+	// handler only dispatches the RPC onto another goroutine and returns
+	// immediately (see nphttp2's OnRead); unlike the native HTTP/2 path,
+	// there's no frame-reading loop here to keep this function, and thus
+	// the connection, alive while that goroutine runs. Block until
+	// WriteStatus marks the stream done so the caller's deferred Close
+	// doesn't cut the response off mid-write.
+	<-s.done()
+}
+
+// writeHeader sends the response HEADERS frame (":status: 200",
+// "content-type: application/grpc") that must precede a native HTTP/2
+// stream's first DATA frame or trailers, if it hasn't been sent already.
+// Callers must hold t.writeMu.
+func (t *ServerTransport) writeHeader(s *Stream) error {
+	if s.headerSent {
+		return nil
+	}
+	s.headerSent = true
+	t.encBuf.Reset()
+	if err := t.enc.WriteField(hpack.HeaderField{Name: ":status", Value: "200"}); err != nil {
+		return err
+	}
+	if err := t.enc.WriteField(hpack.HeaderField{Name: "content-type", Value: "application/grpc"}); err != nil {
+		return err
+	}
+	return t.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      s.id,
+		EndHeaders:    true,
+		BlockFragment: t.encBuf.Bytes(),
+	})
+}
+
+// WriteStatus sends the gRPC trailers (grpc-status and, if set,
+// grpc-message) that end the stream: real HTTP/2 trailers for a native
+// connection, or a trailing 0x80-prefixed frame for gRPC-Web. It always
+// marks the stream done, even on error, so anyone blocked in s.done()
+// (handleGRPCWebStream) isn't stuck waiting for a status that failed to
+// send.
+func (t *ServerTransport) WriteStatus(s *Stream, st *status.Status) error {
+	defer s.markDone()
+	defer func() {
+		// the stream is finished and no longer counts as active.
+		t.mu.Lock()
+		delete(t.streams, s.id)
+		t.mu.Unlock()
+	}()
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	trailers := fmt.Sprintf("grpc-status: %d\r\n", st.Code())
+	if st.Message() != "" {
+		trailers += fmt.Sprintf("grpc-message: %s\r\n", st.Message())
+	}
+	if t.opts.grpcWeb {
+		var frame bytes.Buffer
+		frame.WriteByte(0x80) // MSB set marks a trailer frame, see grpc-web spec.
+		var lenBuf [4]byte
+		binary.BigEndian.PutUint32(lenBuf[:], uint32(len(trailers)))
+		frame.Write(lenBuf[:])
+		frame.WriteString(trailers)
+		if _, err := s.webWriter().Write(frame.Bytes()); err != nil {
+			return err
+		}
+		if s.b64w != nil {
+			// flush the final base64 group; -text is one continuous base64
+			// stream for the whole body, not one group per frame.
+			return s.b64w.Close()
+		}
+		return nil
+	}
+
+	if err := t.writeHeader(s); err != nil {
+		return err
+	}
+	t.encBuf.Reset()
+	if err := t.enc.WriteField(hpack.HeaderField{Name: "grpc-status", Value: fmt.Sprintf("%d", st.Code())}); err != nil {
+		return err
+	}
+	if st.Message() != "" {
+		if err := t.enc.WriteField(hpack.HeaderField{Name: "grpc-message", Value: st.Message()}); err != nil {
+			return err
+		}
+	}
+	return t.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      s.id,
+		EndStream:     true,
+		EndHeaders:    true,
+		BlockFragment: t.encBuf.Bytes(),
+	})
+}
+
+// Stream is one RPC's view of a ServerTransport: an incoming byte stream
+// (the gRPC length-prefixed messages the client sent) and the call's method
+// name and context.
+type Stream struct {
+	t      *ServerTransport
+	id     uint32
+	ctx    context.Context
+	method string
+	reads  chan []byte
+	buf    []byte
+
+	headerSent bool
+	b64w       *base64.Encoder // set lazily for a grpc-web-text response
+
+	doneOnce sync.Once
+	doneCh   chan struct{}
+}
+
+// newStream creates a Stream ready to be registered with t and handed to
+// HandleStreams's handler.
+func newStream(t *ServerTransport, id uint32, ctx context.Context) *Stream {
+	return &Stream{t: t, id: id, ctx: ctx, reads: make(chan []byte, 8), doneCh: make(chan struct{})}
+}
+
+// webWriter returns the io.Writer response bytes are written through on the
+// gRPC-Web path: the raw connection for -proto, or a base64 encoder wrapping
+// it for -text. Callers must hold t.writeMu.
+func (s *Stream) webWriter() io.Writer {
+	if !s.t.opts.grpcWebText {
+		return s.t.conn
+	}
+	if s.b64w == nil {
+		s.b64w = base64.NewEncoder(base64.StdEncoding, s.t.conn)
+	}
+	return s.b64w
+}
+
+// done is closed once WriteStatus has returned, whether or not the RPC's
+// terminal status was sent successfully, i.e. once the stream is finished
+// from the handler's point of view.
+func (s *Stream) done() <-chan struct{} {
+	return s.doneCh
+}
+
+// markDone signals that the RPC has finished. handleGRPCWebStream blocks on
+// done() before returning, since returning tears down the connection
+// (HandleStreams returns, OnRead's deferred tr.Close runs) and would
+// otherwise race the handler goroutine still writing the response.
+func (s *Stream) markDone() {
+	s.doneOnce.Do(func() { close(s.doneCh) })
+}
+
+// Context returns the stream's context, derived from the transport's
+// HandleStreams setHeader callback.
+func (s *Stream) Context() context.Context { return s.ctx }
+
+// Method returns the RPC's full method name as the request path, e.g.
+// "/grpc.health.v1.Health/Check".
+func (s *Stream) Method() string { return s.method }
+
+// Read implements io.Reader over the stream's incoming DATA frames, for use
+// by the higher-level nphttp2.Stream that decodes gRPC messages from it.
+func (s *Stream) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		chunk, ok := <-s.reads
+		if !ok {
+			return 0, io.EOF
+		}
+		s.buf = chunk
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// Write implements io.Writer over the stream's outgoing DATA frames.
+func (s *Stream) Write(p []byte) (int, error) {
+	s.t.writeMu.Lock()
+	defer s.t.writeMu.Unlock()
+	if s.t.opts.grpcWeb {
+		if _, err := s.webWriter().Write(p); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if err := s.t.writeHeader(s); err != nil {
+		return 0, err
+	}
+	if err := s.t.framer.WriteData(s.id, false, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2021 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nphttp2
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/netpoll"
+)
+
+// gRPC-Web content types, see
+// https://github.com/grpc/grpc-web/blob/master/doc/spec.md
+const (
+	contentTypeGRPCWeb      = "application/grpc-web"
+	contentTypeGRPCWebProto = "application/grpc-web+proto"
+	contentTypeGRPCWebText  = "application/grpc-web-text"
+)
+
+// WithGRPCWeb lets the nphttp2 server additionally accept gRPC-Web requests
+// (Content-Type: application/grpc-web, application/grpc-web+proto or the
+// base64-encoded application/grpc-web-text) from browser clients, in
+// addition to native HTTP/2 gRPC. When enabled, OnRead sniffs the
+// Content-Type of each incoming connection before handing it to
+// grpcTransport.NewServerTransport, which frames the response body (data
+// frames and a trailing 0x80-prefixed trailer frame) accordingly.
+//
+// This only covers the common gRPC-Web deployment: a plain HTTP/1.1
+// connection, whether that's a browser's XHR/fetch request directly or one
+// forwarded by a gRPC-Web proxy (e.g. Envoy's grpc_web filter) that already
+// terminated HTTP/2 upstream. A client that opens a native HTTP/2
+// connection and sets a gRPC-Web Content-Type on it is not detected: see
+// peekContentType.
+func WithGRPCWeb(enable bool) Option {
+	return func(t *svrTransHandler) {
+		t.enableGRPCWeb = enable
+	}
+}
+
+// isGRPCWebContentType reports whether contentType names one of the
+// gRPC-Web wire formats, and whether it is the base64-encoded "-text"
+// variant used by <grpc-web-text> environments that can't send binary
+// request bodies.
+func isGRPCWebContentType(contentType string) (web, text bool) {
+	switch {
+	case strings.HasPrefix(contentType, contentTypeGRPCWebText):
+		return true, true
+	case strings.HasPrefix(contentType, contentTypeGRPCWebProto),
+		contentType == contentTypeGRPCWeb,
+		strings.HasPrefix(contentType, contentTypeGRPCWeb+";"):
+		return true, false
+	default:
+		return false, false
+	}
+}
+
+// maxHeaderPeekWait bounds how long peekContentType will wait for the
+// HTTP/1.1 header block to fully arrive before giving up and falling back to
+// the native HTTP/2 transport. It only ever peeks bytes reader.Len() already
+// reports as buffered, never reader.Peek(n) for an n that isn't buffered yet:
+// OnActive sets an infinite read timeout, so a blocking Peek would deadlock
+// forever on a native HTTP/2 client, whose initial flight is far smaller
+// than a full header block.
+const maxHeaderPeekWait = 200 * time.Millisecond
+
+// peekContentType sniffs the Content-Type header of conn without consuming
+// it, so OnRead can decide whether to hand the connection to the native
+// HTTP/2 gRPC transport or the gRPC-Web one. It only recognizes the
+// HTTP/1.1 request line used by browsers that fall back to non-h2
+// connections; a connection that opens with the "PRI * HTTP/2.0" HTTP/2
+// connection preface is always left to grpcTransport as plain gRPC,
+// regardless of what Content-Type its HEADERS frame carries. A gRPC-Web
+// client that insists on HTTP/2 is therefore out of scope: its trailers are
+// still sent as real HTTP/2 trailers, not the 0x80-prefixed trailer frame
+// the gRPC-Web wire format requires, so such a client won't be able to read
+// them.
+//
+// The request line and headers can arrive split across more than one TCP
+// segment, so this polls the bytes already buffered until it finds the
+// blank line terminating the header block, or maxHeaderPeekWait passes.
+func peekContentType(conn netpoll.Connection) (contentType string, ok bool) {
+	reader := conn.Reader()
+	deadline := time.Now().Add(maxHeaderPeekWait)
+	var peeked []byte
+	for {
+		if n := reader.Len(); n > 0 {
+			var err error
+			peeked, err = reader.Peek(n)
+			if err != nil || len(peeked) == 0 {
+				return "", false
+			}
+			if strings.HasPrefix(string(peeked), "PRI * HTTP/2.0") {
+				// native HTTP/2 preface, not an HTTP/1.1 gRPC-Web request.
+				return "", false
+			}
+			if bytes.Contains(peeked, []byte("\r\n\r\n")) {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			// the header block never fully arrived in time; whatever was
+			// peeked, if anything, is all we'll try to parse.
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(peeked))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			break
+		}
+		const header = "content-type:"
+		if lower := strings.ToLower(line); strings.HasPrefix(lower, header) {
+			return strings.TrimSpace(line[len(header):]), true
+		}
+	}
+	return "", false
+}
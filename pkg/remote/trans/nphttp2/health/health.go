@@ -0,0 +1,210 @@
+/*
+ * Copyright 2021 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package health is a built-in implementation of the standard
+// grpc.health.v1.Health service (see
+// https://github.com/grpc/grpc/blob/master/doc/health-checking.md), so that
+// Kitex servers speaking nphttp2/gRPC can answer Kubernetes liveness/readiness
+// probes, Envoy active health checks and grpc-go clients configured with
+// grpc.WithHealthCheckConfig without the user having to hand-write the proto
+// service themselves. Check/Watch exchange the real grpc_health_v1 messages,
+// so their wire bytes are understood by any standard gRPC client.
+package health
+
+import (
+	"context"
+	"sync"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/codes"
+	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/status"
+)
+
+// ServingStatus is the serving status of a service, aliasing
+// grpc_health_v1.HealthCheckResponse_ServingStatus so callers don't need to
+// import that package themselves.
+type ServingStatus = healthpb.HealthCheckResponse_ServingStatus
+
+const (
+	Unknown        = healthpb.HealthCheckResponse_UNKNOWN
+	Serving        = healthpb.HealthCheckResponse_SERVING
+	NotServing     = healthpb.HealthCheckResponse_NOT_SERVING
+	ServiceUnknown = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+)
+
+// HealthCheckRequest is the request message of Check/Watch.
+type HealthCheckRequest = healthpb.HealthCheckRequest
+
+// HealthCheckResponse is the response message of Check/Watch.
+type HealthCheckResponse = healthpb.HealthCheckResponse
+
+// Server is a built-in health checking service. The zero value is not
+// usable; create one with NewServer.
+//
+// The empty service name ("") represents the overall health of the server,
+// as described by the health checking protocol.
+type Server struct {
+	mu sync.RWMutex
+	// shutdown, once true, causes Check and Watch to return NOT_SERVING for
+	// all services regardless of statusMap, matching grpc-go's behaviour on
+	// GracefulStop.
+	shutdown  bool
+	statusMap map[string]ServingStatus
+	watchers  map[string]map[chan ServingStatus]struct{}
+}
+
+// NewServer creates a health Server with no services registered. Until
+// RegisterService/SetServingStatus is called for a service, Check reports
+// NotFound for it and Watch reports SERVICE_UNKNOWN.
+func NewServer() *Server {
+	return &Server{
+		statusMap: make(map[string]ServingStatus),
+		watchers:  make(map[string]map[chan ServingStatus]struct{}),
+	}
+}
+
+// RegisterService declares that service exists and sets its initial status,
+// typically called once per registered Kitex service at server-build time.
+func (s *Server) RegisterService(service string, status ServingStatus) {
+	s.SetServingStatus(service, status)
+}
+
+// SetServingStatus updates the serving status of service and notifies any
+// active Watch streams for it. Updating the status of the empty service ("")
+// reports the overall status of the server.
+func (s *Server) SetServingStatus(service string, status ServingStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown {
+		// once the server is shutting down, SetServingStatus is a no-op so
+		// that a late caller can't resurrect a service's status.
+		return
+	}
+	s.setServingStatusLocked(service, status)
+}
+
+func (s *Server) setServingStatusLocked(service string, status ServingStatus) {
+	s.statusMap[service] = status
+	for c := range s.watchers[service] {
+		select {
+		case c <- status:
+		default:
+			// c is full with a status Watch hasn't read yet; drain it and
+			// push the latest one in its place so the watcher coalesces to
+			// the newest status instead of permanently missing this update.
+			select {
+			case <-c:
+			default:
+			}
+			select {
+			case c <- status:
+			default:
+			}
+		}
+	}
+}
+
+// Shutdown marks all services as NOT_SERVING, and is typically invoked from
+// the server's graceful-shutdown path so in-flight health checks stop
+// reporting SERVING while connections are being drained.
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdown = true
+	for service := range s.statusMap {
+		s.setServingStatusLocked(service, NotServing)
+	}
+}
+
+// Resume reverses the effect of Shutdown, restoring normal SetServingStatus
+// behaviour. Statuses are left as NOT_SERVING until explicitly set again.
+func (s *Server) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdown = false
+}
+
+// Check implements the unary Check RPC. The empty service ("") is not
+// special-cased: like grpc-go's default Server.Check, it reports NotFound
+// until something (typically the application, at startup) calls
+// SetServingStatus(""), so a probe against it can't report healthy before
+// the server has actually said so.
+func (s *Server) Check(ctx context.Context, req *HealthCheckRequest) (*HealthCheckResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.shutdown {
+		return &HealthCheckResponse{Status: NotServing}, nil
+	}
+	if st, ok := s.statusMap[req.Service]; ok {
+		return &HealthCheckResponse{Status: st}, nil
+	}
+	return nil, status.New(codes.NotFound, "unknown service").Err()
+}
+
+// Sender is the minimal interface a streaming transport needs to implement
+// so that Watch can push HealthCheckResponse updates to the client; it is
+// satisfied by a *nphttp2.Stream's SendMsg method.
+type Sender interface {
+	SendMsg(interface{}) error
+}
+
+// Watch implements the server-streaming Watch RPC: it sends the current
+// status for req.Service immediately, then an update whenever a later
+// SetServingStatus call changes it, until ctx is cancelled (the client
+// disconnects). A status equal to the last one sent is not resent, and
+// changes made faster than the stream sends them are coalesced to the
+// latest.
+func (s *Server) Watch(ctx context.Context, req *HealthCheckRequest, send Sender) error {
+	update := make(chan ServingStatus, 1)
+
+	s.mu.Lock()
+	st, ok := s.statusMap[req.Service]
+	if !ok {
+		st = ServiceUnknown
+	}
+	if s.shutdown {
+		st = NotServing
+	}
+	update <- st
+	if s.watchers[req.Service] == nil {
+		s.watchers[req.Service] = make(map[chan ServingStatus]struct{})
+	}
+	s.watchers[req.Service][update] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.watchers[req.Service], update)
+		s.mu.Unlock()
+	}()
+
+	var last ServingStatus = -1
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case st := <-update:
+			if st == last {
+				continue
+			}
+			last = st
+			if err := send.SendMsg(&HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+		}
+	}
+}
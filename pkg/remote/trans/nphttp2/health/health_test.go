@@ -0,0 +1,180 @@
+/*
+ * Copyright 2021 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package health
+
+import (
+	"context"
+	"testing"
+)
+
+func TestServerCheckOverallStatusNotFoundUntilSet(t *testing.T) {
+	s := NewServer()
+	resp, err := s.Check(context.Background(), &HealthCheckRequest{})
+	if err == nil {
+		t.Fatal("Check() error = nil, want an error before SetServingStatus(\"\") is ever called")
+	}
+	if resp != nil {
+		t.Fatalf("Check() resp = %v, want nil", resp)
+	}
+
+	s.SetServingStatus("", Serving)
+	resp, err = s.Check(context.Background(), &HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Check() error = %v, want nil", err)
+	}
+	if resp.Status != Serving {
+		t.Fatalf("Check() status = %v, want Serving", resp.Status)
+	}
+}
+
+func TestServerCheckUnknownService(t *testing.T) {
+	s := NewServer()
+	resp, err := s.Check(context.Background(), &HealthCheckRequest{Service: "unregistered"})
+	if err == nil {
+		t.Fatal("Check() error = nil, want an error for an unregistered service")
+	}
+	if resp != nil {
+		t.Fatalf("Check() resp = %v, want nil", resp)
+	}
+}
+
+func TestServerSetServingStatus(t *testing.T) {
+	s := NewServer()
+	s.SetServingStatus("svc", NotServing)
+	resp, err := s.Check(context.Background(), &HealthCheckRequest{Service: "svc"})
+	if err != nil {
+		t.Fatalf("Check() error = %v, want nil", err)
+	}
+	if resp.Status != NotServing {
+		t.Fatalf("Check() status = %v, want NotServing", resp.Status)
+	}
+}
+
+func TestServerShutdown(t *testing.T) {
+	s := NewServer()
+	s.SetServingStatus("svc", Serving)
+
+	s.Shutdown()
+
+	resp, err := s.Check(context.Background(), &HealthCheckRequest{Service: "svc"})
+	if err != nil {
+		t.Fatalf("Check() error = %v, want nil", err)
+	}
+	if resp.Status != NotServing {
+		t.Fatalf("Check() status after Shutdown = %v, want NotServing", resp.Status)
+	}
+
+	// the overall status ("") must also flip to NOT_SERVING, even though it
+	// was never explicitly registered.
+	resp, err = s.Check(context.Background(), &HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Check() error = %v, want nil", err)
+	}
+	if resp.Status != NotServing {
+		t.Fatalf("Check() overall status after Shutdown = %v, want NotServing", resp.Status)
+	}
+
+	// SetServingStatus is a no-op once shut down.
+	s.SetServingStatus("svc", Serving)
+	resp, _ = s.Check(context.Background(), &HealthCheckRequest{Service: "svc"})
+	if resp.Status != NotServing {
+		t.Fatalf("SetServingStatus after Shutdown changed status to %v, want it to stay NotServing", resp.Status)
+	}
+}
+
+func TestServerResume(t *testing.T) {
+	s := NewServer()
+	s.Shutdown()
+	s.Resume()
+	s.SetServingStatus("svc", Serving)
+
+	resp, err := s.Check(context.Background(), &HealthCheckRequest{Service: "svc"})
+	if err != nil {
+		t.Fatalf("Check() error = %v, want nil", err)
+	}
+	if resp.Status != Serving {
+		t.Fatalf("Check() status after Resume = %v, want Serving", resp.Status)
+	}
+}
+
+func TestServerWatch(t *testing.T) {
+	s := NewServer()
+	s.SetServingStatus("svc", Serving)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender := newFakeSender()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Watch(ctx, &HealthCheckRequest{Service: "svc"}, sender)
+	}()
+
+	if got := <-sender.sent; got.Status != Serving {
+		t.Fatalf("first Watch update = %v, want Serving", got.Status)
+	}
+
+	s.SetServingStatus("svc", NotServing)
+	if got := <-sender.sent; got.Status != NotServing {
+		t.Fatalf("second Watch update = %v, want NotServing", got.Status)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Watch() error = %v, want nil once ctx is cancelled", err)
+	}
+}
+
+func TestSetServingStatusCoalescesToLatest(t *testing.T) {
+	s := NewServer()
+	// a watcher that hasn't read yet, as if Watch's goroutine is slow.
+	update := make(chan ServingStatus, 1)
+	s.mu.Lock()
+	s.watchers["svc"] = map[chan ServingStatus]struct{}{update: {}}
+	s.mu.Unlock()
+
+	s.SetServingStatus("svc", NotServing)
+	s.SetServingStatus("svc", Serving)
+
+	select {
+	case got := <-update:
+		if got != Serving {
+			t.Fatalf("channel = %v, want it to coalesce to the latest status (Serving)", got)
+		}
+	default:
+		t.Fatal("channel empty, want the coalesced latest status")
+	}
+
+	select {
+	case extra := <-update:
+		t.Fatalf("unexpected extra value %v buffered on the channel", extra)
+	default:
+	}
+}
+
+type fakeSender struct {
+	sent chan *HealthCheckResponse
+}
+
+func newFakeSender() *fakeSender {
+	return &fakeSender{sent: make(chan *HealthCheckResponse, 4)}
+}
+
+func (f *fakeSender) SendMsg(m interface{}) error {
+	f.sent <- m.(*HealthCheckResponse)
+	return nil
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2021 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nphttp2
+
+import (
+	"context"
+
+	"github.com/cloudwego/kitex/pkg/serviceinfo"
+)
+
+// ServerInfo describes the RPC an interceptor is wrapping, analogous to
+// grpc.UnaryServerInfo/grpc.StreamServerInfo combined.
+type ServerInfo struct {
+	// FullMethod is "/package.Service/Method", as parsed from the stream.
+	FullMethod string
+	// MethodInfo is the serviceinfo.MethodInfo the server resolved
+	// FullMethod's method name against, or nil if it couldn't be resolved
+	// (e.g. the method doesn't exist on the registered service).
+	MethodInfo serviceinfo.MethodInfo
+	// IsClientStream/IsServerStream report the streaming mode of the
+	// method, mirroring grpc.StreamServerInfo.
+	IsClientStream bool
+	IsServerStream bool
+}
+
+// UnaryHandler is the next step in a unary interceptor chain; the last
+// UnaryHandler in the chain invokes the user's registered method. req is
+// always the *streaming.Args passed to the server's inkHdlFunc.
+type UnaryHandler func(ctx context.Context, req interface{}) (interface{}, error)
+
+// UnaryServerInterceptor mirrors grpc.UnaryServerInterceptor, letting
+// existing grpc-go unary middleware (auth, rate limiting, metrics,
+// OpenTelemetry, ...) run around a Kitex nphttp2 server's non-streaming
+// methods with minimal rewriting.
+type UnaryServerInterceptor func(ctx context.Context, req interface{}, info *ServerInfo, handler UnaryHandler) (interface{}, error)
+
+// StreamHandler is the next step in a stream interceptor chain; see
+// UnaryHandler.
+type StreamHandler func(ctx context.Context, req interface{}) (interface{}, error)
+
+// StreamServerInterceptor mirrors grpc.StreamServerInterceptor for
+// client-streaming, server-streaming and bidi-streaming methods.
+type StreamServerInterceptor func(ctx context.Context, req interface{}, info *ServerInfo, handler StreamHandler) (interface{}, error)
+
+// WithUnaryServerInterceptors registers interceptors to run, in order,
+// around every non-streaming method the server invokes. Calling it more
+// than once appends to the existing chain.
+//
+// req is always the opaque *streaming.Args the transport passes to
+// inkHdlFunc, not the decoded request message, and the interceptor's
+// returned resp is discarded: the real response is whatever the registered
+// method handler already wrote to the stream. This is enough to port
+// grpc-go middleware that only needs ctx/info (auth, rate limiting, most
+// metrics/OpenTelemetry spans), but anything that inspects or rewrites the
+// request/response message itself won't see what it expects.
+func WithUnaryServerInterceptors(interceptors ...UnaryServerInterceptor) Option {
+	return func(t *svrTransHandler) {
+		t.unaryInterceptors = append(t.unaryInterceptors, interceptors...)
+	}
+}
+
+// WithStreamServerInterceptors registers interceptors to run, in order,
+// around every streaming method the server invokes. Calling it more than
+// once appends to the existing chain.
+//
+// As with WithUnaryServerInterceptors, req is the opaque *streaming.Args,
+// not a decoded message, and the returned resp is discarded.
+func WithStreamServerInterceptors(interceptors ...StreamServerInterceptor) Option {
+	return func(t *svrTransHandler) {
+		t.streamInterceptors = append(t.streamInterceptors, interceptors...)
+	}
+}
+
+// chainUnaryInterceptors composes interceptors into a single
+// UnaryServerInterceptor that runs them in order, each wrapping the next,
+// with the last one wrapping the real handler. It returns nil if
+// interceptors is empty so callers can skip the indirection entirely.
+func chainUnaryInterceptors(interceptors []UnaryServerInterceptor) UnaryServerInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+	return func(ctx context.Context, req interface{}, info *ServerInfo, handler UnaryHandler) (interface{}, error) {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			ic, next := interceptors[i], chained
+			chained = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return ic(ctx, req, info, next)
+			}
+		}
+		return chained(ctx, req)
+	}
+}
+
+// chainStreamInterceptors is chainUnaryInterceptors' StreamServerInterceptor
+// counterpart.
+func chainStreamInterceptors(interceptors []StreamServerInterceptor) StreamServerInterceptor {
+	switch len(interceptors) {
+	case 0:
+		return nil
+	case 1:
+		return interceptors[0]
+	}
+	return func(ctx context.Context, req interface{}, info *ServerInfo, handler StreamHandler) (interface{}, error) {
+		chained := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			ic, next := interceptors[i], chained
+			chained = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return ic(ctx, req, info, next)
+			}
+		}
+		return chained(ctx, req)
+	}
+}
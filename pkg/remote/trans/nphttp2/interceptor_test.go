@@ -0,0 +1,94 @@
+/*
+ * Copyright 2021 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nphttp2
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChainUnaryInterceptorsEmpty(t *testing.T) {
+	if chain := chainUnaryInterceptors(nil); chain != nil {
+		t.Fatalf("chainUnaryInterceptors(nil) = %v, want nil", chain)
+	}
+}
+
+func TestChainUnaryInterceptorsOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *ServerInfo, handler UnaryHandler) (interface{}, error) {
+			order = append(order, "before:"+name)
+			resp, err := handler(ctx, req)
+			order = append(order, "after:"+name)
+			return resp, err
+		}
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		order = append(order, "handler")
+		return "resp", nil
+	}
+
+	chain := chainUnaryInterceptors([]UnaryServerInterceptor{mark("a"), mark("b")})
+	resp, err := chain(context.Background(), "req", &ServerInfo{}, handler)
+	if err != nil {
+		t.Fatalf("chain() error = %v, want nil", err)
+	}
+	if resp != "resp" {
+		t.Fatalf("chain() resp = %v, want %q", resp, "resp")
+	}
+
+	want := []string{"before:a", "before:b", "handler", "after:b", "after:a"}
+	if len(order) != len(want) {
+		t.Fatalf("call order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("call order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestChainStreamInterceptorsOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) StreamServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *ServerInfo, handler StreamHandler) (interface{}, error) {
+			order = append(order, "before:"+name)
+			resp, err := handler(ctx, req)
+			order = append(order, "after:"+name)
+			return resp, err
+		}
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		order = append(order, "handler")
+		return nil, nil
+	}
+
+	chain := chainStreamInterceptors([]StreamServerInterceptor{mark("a"), mark("b"), mark("c")})
+	if _, err := chain(context.Background(), nil, &ServerInfo{}, handler); err != nil {
+		t.Fatalf("chain() error = %v, want nil", err)
+	}
+
+	want := []string{"before:a", "before:b", "before:c", "handler", "after:c", "after:b", "after:a"}
+	if len(order) != len(want) {
+		t.Fatalf("call order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("call order = %v, want %v", order, want)
+		}
+	}
+}
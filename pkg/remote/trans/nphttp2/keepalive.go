@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nphttp2
+
+import (
+	"time"
+
+	grpcTransport "github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/grpc"
+)
+
+// KeepaliveParams configures the nphttp2 server's HTTP/2 PING behaviour and
+// connection lifetime, mirroring grpc-go's keepalive.ServerParameters. A
+// zero field takes grpcTransport's default, which does not always match
+// grpc-go's: zero MaxConnectionIdle and MaxConnectionAge mean no limit, zero
+// Timeout means 20 seconds, zero Time disables keepalive PINGs, and zero
+// MaxConnectionAgeGrace closes the connection as soon as its GOAWAY is sent.
+type KeepaliveParams struct {
+	// MaxConnectionIdle is the duration after which an idle connection (no
+	// active streams) is closed.
+	MaxConnectionIdle time.Duration
+	// MaxConnectionAge is the duration after which a connection is closed
+	// by sending a GOAWAY, regardless of activity.
+	MaxConnectionAge time.Duration
+	// MaxConnectionAgeGrace is the additional time given to streams in
+	// flight after MaxConnectionAge before the connection is force-closed.
+	MaxConnectionAgeGrace time.Duration
+	// Time is the interval between HTTP/2 PINGs sent to idle connections
+	// to check they're still alive.
+	Time time.Duration
+	// Timeout is how long to wait for a PING ack before the connection is
+	// considered dead and closed.
+	Timeout time.Duration
+}
+
+// KeepaliveEnforcementPolicy configures how strict the server is about
+// client-initiated keepalive PINGs, mirroring grpc-go's
+// keepalive.EnforcementPolicy. A client that pings more often than allowed
+// is disconnected with GOAWAY(ENHANCE_YOUR_CALM).
+type KeepaliveEnforcementPolicy struct {
+	// MinTime is the minimum interval clients are permitted to send
+	// keepalive PINGs.
+	MinTime time.Duration
+	// PermitWithoutStream, if true, allows clients to send keepalive PINGs
+	// even when there are no active streams.
+	PermitWithoutStream bool
+}
+
+// WithKeepaliveParams configures the server's HTTP/2 keepalive PING
+// behaviour and connection age limits, letting long-lived streaming
+// deployments behind load balancers recycle connections in a controlled
+// way instead of relying solely on an infinite read timeout.
+func WithKeepaliveParams(kp KeepaliveParams) Option {
+	return func(t *svrTransHandler) {
+		t.keepaliveParams = kp
+	}
+}
+
+// WithKeepaliveEnforcementPolicy configures how the server reacts to
+// clients that ping too aggressively.
+func WithKeepaliveEnforcementPolicy(kep KeepaliveEnforcementPolicy) Option {
+	return func(t *svrTransHandler) {
+		t.keepaliveEnforcementPolicy = kep
+	}
+}
+
+// keepaliveTransportOptions translates the handler's configured keepalive
+// settings into grpcTransport.ServerTransportOptions for
+// grpcTransport.NewServerTransport.
+func (t *svrTransHandler) keepaliveTransportOptions() []grpcTransport.ServerTransportOption {
+	return []grpcTransport.ServerTransportOption{
+		grpcTransport.WithKeepaliveParams(grpcTransport.ServerKeepaliveParams{
+			MaxConnectionIdle:     t.keepaliveParams.MaxConnectionIdle,
+			MaxConnectionAge:      t.keepaliveParams.MaxConnectionAge,
+			MaxConnectionAgeGrace: t.keepaliveParams.MaxConnectionAgeGrace,
+			Time:                  t.keepaliveParams.Time,
+			Timeout:               t.keepaliveParams.Timeout,
+		}),
+		grpcTransport.WithKeepaliveEnforcementPolicy(grpcTransport.ServerKeepaliveEnforcementPolicy{
+			MinTime:             t.keepaliveEnforcementPolicy.MinTime,
+			PermitWithoutStream: t.keepaliveEnforcementPolicy.PermitWithoutStream,
+		}),
+	}
+}
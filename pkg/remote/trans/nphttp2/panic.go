@@ -0,0 +1,75 @@
+/*
+ * Copyright 2021 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nphttp2
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+
+	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/codes"
+	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/status"
+)
+
+// PanicHandler converts a value recovered from a panicking method handler
+// into the gRPC status returned to the client on that stream, instead of
+// leaving the client hanging until the transport tears down the
+// connection. Register a custom one with WithPanicHandler to map specific
+// panic types (e.g. context.DeadlineExceeded, a custom AbortError) to more
+// specific codes than the default codes.Internal.
+type PanicHandler func(ctx context.Context, panicValue interface{}) *status.Status
+
+// WithPanicHandler overrides how a recovered panic is turned into the
+// status written back to the client.
+func WithPanicHandler(ph PanicHandler) Option {
+	return func(t *svrTransHandler) {
+		t.panicHandler = ph
+	}
+}
+
+// WithPanicStackInStatus opts in to attaching the panic value and the
+// recovered goroutine's stack trace as a DebugInfo detail of the status
+// produced by the default PanicHandler; the status message stays generic.
+// It is off by default so servers don't leak internals to clients; the
+// full panic value and stack are always logged locally regardless.
+func WithPanicStackInStatus(enable bool) Option {
+	return func(t *svrTransHandler) {
+		t.panicStackInStatus = enable
+	}
+}
+
+// defaultPanicHandler reports a generic codes.Internal status with a clean
+// message; when panicStackInStatus was opted into, the panic value and
+// stack are attached as a DebugInfo detail instead of being folded into the
+// message, so clients that don't care can ignore it.
+func (t *svrTransHandler) defaultPanicHandler(ctx context.Context, panicValue interface{}) *status.Status {
+	st := status.New(codes.Internal, "internal error")
+	if !t.panicStackInStatus {
+		return st
+	}
+	stWithDetails, err := st.WithDetails(&errdetails.DebugInfo{
+		StackEntries: strings.Split(string(debug.Stack()), "\n"),
+		Detail:       fmt.Sprintf("panic: %v", panicValue),
+	})
+	if err != nil {
+		return st
+	}
+	return stWithDetails
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright 2021 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package reflection is a built-in implementation of the standard
+// grpc.reflection.v1alpha.ServerReflection service (see
+// https://github.com/grpc/grpc/blob/master/doc/server-reflection.md), so
+// that tools such as grpcurl, evans and Postman's gRPC client can discover
+// the services, methods and messages a Kitex server exposes without the
+// user writing any extra Thrift/proto IDL for it. It exchanges the real
+// grpc_reflection_v1alpha messages, so their wire bytes are understood by
+// any standard gRPC reflection client.
+package reflection
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+
+	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/codes"
+	"github.com/cloudwego/kitex/pkg/serviceinfo"
+)
+
+// ServiceName is the fully-qualified name ServerReflectionInfo is served
+// under.
+const ServiceName = "grpc.reflection.v1alpha.ServerReflection"
+
+// ExtensionResolver answers FileContainingExtension and
+// AllExtensionNumbersOfType requests, typically backed by a real proto
+// registry (e.g. protoregistry.GlobalFiles). It's optional: a Registry
+// with none configured simply reports that it has no extensions.
+type ExtensionResolver interface {
+	FileContainingExtension(extendedType string, extensionNumber int32) (*descriptorpb.FileDescriptorProto, bool)
+	AllExtensionNumbersOfType(extendedType string) ([]int32, bool)
+}
+
+// Registry is the built-in reflection service's backing store. The zero
+// value is not usable; create one with NewRegistry.
+type Registry struct {
+	mu        sync.RWMutex
+	services  map[string]*serviceinfo.ServiceInfo
+	files     map[string]*descriptorpb.FileDescriptorProto
+	symbols   map[string]*descriptorpb.FileDescriptorProto
+	extension ExtensionResolver
+}
+
+// NewRegistry creates an empty Registry; register services and, if the
+// server was built from proto IDL, their FileDescriptorProto with
+// RegisterService/RegisterFileDescriptor.
+func NewRegistry() *Registry {
+	return &Registry{
+		services: make(map[string]*serviceinfo.ServiceInfo),
+		files:    make(map[string]*descriptorpb.FileDescriptorProto),
+		symbols:  make(map[string]*descriptorpb.FileDescriptorProto),
+	}
+}
+
+// RegisterService makes name (the fully-qualified "package.Service") and
+// its methods discoverable via ListServices.
+func (r *Registry) RegisterService(name string, svc *serviceinfo.ServiceInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.services[name] = svc
+}
+
+// RegisterFileDescriptor indexes fd and the top-level message, service and
+// enum symbols it declares, so FileByFilename and FileContainingSymbol can
+// answer from it. Nested messages and enums, and service methods, are not
+// indexed. Kitex servers built purely from Thrift IDL have no descriptor to
+// register and should rely on RegisterService alone.
+func (r *Registry) RegisterFileDescriptor(fd *descriptorpb.FileDescriptorProto) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files[fd.GetName()] = fd
+	pkg := fd.GetPackage()
+	for _, m := range fd.GetMessageType() {
+		r.symbols[qualify(pkg, m.GetName())] = fd
+	}
+	for _, s := range fd.GetService() {
+		r.symbols[qualify(pkg, s.GetName())] = fd
+	}
+	for _, e := range fd.GetEnumType() {
+		r.symbols[qualify(pkg, e.GetName())] = fd
+	}
+}
+
+// SetExtensionResolver configures how FileContainingExtension and
+// AllExtensionNumbersOfType are answered.
+func (r *Registry) SetExtensionResolver(resolver ExtensionResolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.extension = resolver
+}
+
+func qualify(pkg, name string) string {
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
+
+func (r *Registry) listServices() []*rpb.ServiceResponse {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	resp := make([]*rpb.ServiceResponse, 0, len(r.services))
+	for name := range r.services {
+		resp = append(resp, &rpb.ServiceResponse{Name: name})
+	}
+	return resp
+}
+
+func (r *Registry) fileByFilename(name string) (*descriptorpb.FileDescriptorProto, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fd, ok := r.files[name]
+	return fd, ok
+}
+
+func (r *Registry) fileContainingSymbol(symbol string) (*descriptorpb.FileDescriptorProto, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fd, ok := r.symbols[symbol]
+	return fd, ok
+}
+
+func (r *Registry) fileContainingExtension(extendedType string, extensionNumber int32) (*descriptorpb.FileDescriptorProto, bool) {
+	r.mu.RLock()
+	resolver := r.extension
+	r.mu.RUnlock()
+	if resolver == nil {
+		return nil, false
+	}
+	return resolver.FileContainingExtension(extendedType, extensionNumber)
+}
+
+func (r *Registry) allExtensionNumbersOfType(extendedType string) ([]int32, bool) {
+	r.mu.RLock()
+	resolver := r.extension
+	r.mu.RUnlock()
+	if resolver == nil {
+		return nil, false
+	}
+	return resolver.AllExtensionNumbersOfType(extendedType)
+}
+
+// Stream is the minimal bidi-streaming interface ServerReflectionInfo needs
+// from the transport; it is satisfied by a *nphttp2.Stream.
+type Stream interface {
+	Context() context.Context
+	RecvMsg(m interface{}) error
+	SendMsg(m interface{}) error
+}
+
+// ServerReflectionInfo serves the single bidi-streaming RPC the reflection
+// service exposes: it answers one ServerReflectionRequest per SendMsg,
+// for as long as the client keeps the stream open.
+func (r *Registry) ServerReflectionInfo(stream Stream) error {
+	for {
+		req := new(rpb.ServerReflectionRequest)
+		if err := stream.RecvMsg(req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := stream.SendMsg(r.handle(req)); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *Registry) handle(req *rpb.ServerReflectionRequest) *rpb.ServerReflectionResponse {
+	switch mr := req.MessageRequest.(type) {
+	case *rpb.ServerReflectionRequest_ListServices:
+		return &rpb.ServerReflectionResponse{
+			MessageResponse: &rpb.ServerReflectionResponse_ListServicesResponse{
+				ListServicesResponse: &rpb.ListServiceResponse{Service: r.listServices()},
+			},
+		}
+	case *rpb.ServerReflectionRequest_FileByFilename:
+		fd, ok := r.fileByFilename(mr.FileByFilename)
+		if !ok {
+			return notFound(fmt.Sprintf("file not found: %s", mr.FileByFilename))
+		}
+		return r.fileDescriptorResponse(fd)
+	case *rpb.ServerReflectionRequest_FileContainingSymbol:
+		fd, ok := r.fileContainingSymbol(mr.FileContainingSymbol)
+		if !ok {
+			return notFound(fmt.Sprintf("symbol not found: %s", mr.FileContainingSymbol))
+		}
+		return r.fileDescriptorResponse(fd)
+	case *rpb.ServerReflectionRequest_FileContainingExtension:
+		ext := mr.FileContainingExtension
+		fd, ok := r.fileContainingExtension(ext.GetContainingType(), ext.GetExtensionNumber())
+		if !ok {
+			return notFound(fmt.Sprintf("extension not found: %s %d", ext.GetContainingType(), ext.GetExtensionNumber()))
+		}
+		return r.fileDescriptorResponse(fd)
+	case *rpb.ServerReflectionRequest_AllExtensionNumbersOfType:
+		nums, ok := r.allExtensionNumbersOfType(mr.AllExtensionNumbersOfType)
+		if !ok {
+			return notFound(fmt.Sprintf("type not found: %s", mr.AllExtensionNumbersOfType))
+		}
+		return &rpb.ServerReflectionResponse{
+			MessageResponse: &rpb.ServerReflectionResponse_AllExtensionNumbersResponse{
+				AllExtensionNumbersResponse: &rpb.ExtensionNumberResponse{
+					BaseTypeName:    mr.AllExtensionNumbersOfType,
+					ExtensionNumber: nums,
+				},
+			},
+		}
+	default:
+		return notFound("invalid MessageRequest")
+	}
+}
+
+// fileDescriptorResponse marshals fd and every file it transitively
+// depends on, in dependency-first order, matching what grpc-go's
+// reflection implementation sends so the client never has to make a
+// separate FileByFilename round-trip just to resolve an import.
+func (r *Registry) fileDescriptorResponse(fd *descriptorpb.FileDescriptorProto) *rpb.ServerReflectionResponse {
+	seen := make(map[string]bool)
+	var files [][]byte
+	var collect func(fd *descriptorpb.FileDescriptorProto) error
+	collect = func(fd *descriptorpb.FileDescriptorProto) error {
+		if seen[fd.GetName()] {
+			return nil
+		}
+		seen[fd.GetName()] = true
+		for _, dep := range fd.GetDependency() {
+			if depFd, ok := r.fileByFilename(dep); ok {
+				if err := collect(depFd); err != nil {
+					return err
+				}
+			}
+		}
+		b, err := proto.Marshal(fd)
+		if err != nil {
+			return err
+		}
+		files = append(files, b)
+		return nil
+	}
+	if err := collect(fd); err != nil {
+		return notFound(err.Error())
+	}
+	return &rpb.ServerReflectionResponse{
+		MessageResponse: &rpb.ServerReflectionResponse_FileDescriptorResponse{
+			FileDescriptorResponse: &rpb.FileDescriptorResponse{FileDescriptorProto: files},
+		},
+	}
+}
+
+func notFound(msg string) *rpb.ServerReflectionResponse {
+	return &rpb.ServerReflectionResponse{
+		MessageResponse: &rpb.ServerReflectionResponse_ErrorResponse{
+			ErrorResponse: &rpb.ErrorResponse{ErrorCode: int32(codes.NotFound), ErrorMessage: msg},
+		},
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2021 CloudWeGo Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package reflection
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+)
+
+func fileProto(name string, deps ...string) *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:       proto.String(name),
+		Dependency: deps,
+	}
+}
+
+// TestFileDescriptorResponseIncludesTransitiveDeps verifies that asking for
+// a file pulls in every file it depends on, transitively, in
+// dependency-first order, so a reflection client never needs a separate
+// FileByFilename round-trip to resolve an import.
+func TestFileDescriptorResponseIncludesTransitiveDeps(t *testing.T) {
+	r := NewRegistry()
+	common := fileProto("common.proto")
+	base := fileProto("base.proto", "common.proto")
+	top := fileProto("top.proto", "base.proto")
+	r.RegisterFileDescriptor(common)
+	r.RegisterFileDescriptor(base)
+	r.RegisterFileDescriptor(top)
+
+	resp := r.fileDescriptorResponse(top)
+	fdResp, ok := resp.MessageResponse.(*rpb.ServerReflectionResponse_FileDescriptorResponse)
+	if !ok {
+		t.Fatalf("MessageResponse = %T, want *ServerReflectionResponse_FileDescriptorResponse", resp.MessageResponse)
+	}
+
+	files := fdResp.FileDescriptorResponse.FileDescriptorProto
+	if len(files) != 3 {
+		t.Fatalf("got %d files, want 3", len(files))
+	}
+
+	wantOrder := []string{"common.proto", "base.proto", "top.proto"}
+	for i, want := range wantOrder {
+		fd := new(descriptorpb.FileDescriptorProto)
+		if err := proto.Unmarshal(files[i], fd); err != nil {
+			t.Fatalf("unmarshal file %d: %v", i, err)
+		}
+		if fd.GetName() != want {
+			t.Fatalf("file %d = %q, want %q (order: %v)", i, fd.GetName(), want, wantOrder)
+		}
+	}
+}
+
+// TestFileDescriptorResponseDedupsDiamondDeps verifies a diamond dependency
+// (top depends on both base and common, common also reachable through
+// base) is only sent once.
+func TestFileDescriptorResponseDedupsDiamondDeps(t *testing.T) {
+	r := NewRegistry()
+	common := fileProto("common.proto")
+	base := fileProto("base.proto", "common.proto")
+	top := fileProto("top.proto", "base.proto", "common.proto")
+	r.RegisterFileDescriptor(common)
+	r.RegisterFileDescriptor(base)
+	r.RegisterFileDescriptor(top)
+
+	resp := r.fileDescriptorResponse(top)
+	fdResp := resp.MessageResponse.(*rpb.ServerReflectionResponse_FileDescriptorResponse)
+	if got := len(fdResp.FileDescriptorResponse.FileDescriptorProto); got != 3 {
+		t.Fatalf("got %d files, want 3 (no duplicates)", got)
+	}
+}
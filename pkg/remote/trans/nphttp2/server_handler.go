@@ -32,6 +32,8 @@ import (
 	"github.com/cloudwego/kitex/pkg/remote/codec/protobuf"
 	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/codes"
 	grpcTransport "github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/grpc"
+	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/health"
+	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/reflection"
 	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/status"
 	"github.com/cloudwego/kitex/pkg/rpcinfo"
 	"github.com/cloudwego/kitex/pkg/serviceinfo"
@@ -39,23 +41,65 @@ import (
 	"github.com/cloudwego/kitex/transport"
 )
 
-type svrTransHandlerFactory struct{}
+const healthCheckServiceName = "grpc.health.v1.Health"
 
-// NewSvrTransHandlerFactory ...
-func NewSvrTransHandlerFactory() remote.ServerTransHandlerFactory {
-	return &svrTransHandlerFactory{}
+const serverReflectionMethodName = "ServerReflectionInfo"
+
+// Option configures the svrTransHandler built by NewSvrTransHandlerFactory.
+type Option func(*svrTransHandler)
+
+// WithHealthServer enables the standard grpc.health.v1.Health service,
+// serving Check and Watch directly from hs without the user registering a
+// Thrift/proto IDL for it. hs's serving status is typically driven by the
+// application via health.Server.SetServingStatus.
+func WithHealthServer(hs *health.Server) Option {
+	return func(t *svrTransHandler) {
+		t.healthSvr = hs
+	}
+}
+
+// WithServerReflection enables the standard
+// grpc.reflection.v1alpha.ServerReflection service, registered as an
+// additional pseudo-service so tools like grpcurl, evans and Postman's
+// gRPC client can discover what reg knows about without the user writing
+// any extra Thrift/proto IDL for it.
+func WithServerReflection(reg *reflection.Registry) Option {
+	return func(t *svrTransHandler) {
+		t.reflectionRegistry = reg
+	}
+}
+
+type svrTransHandlerFactory struct {
+	opts []Option
+}
+
+// NewSvrTransHandlerFactory creates a ServerTransHandlerFactory for the
+// nphttp2 (gRPC) transport. opts apply to every svrTransHandler it builds.
+func NewSvrTransHandlerFactory(opts ...Option) remote.ServerTransHandlerFactory {
+	return &svrTransHandlerFactory{opts: opts}
 }
 
 func (f *svrTransHandlerFactory) NewTransHandler(opt *remote.ServerOption) (remote.ServerTransHandler, error) {
-	return newSvrTransHandler(opt)
+	return newSvrTransHandler(opt, f.opts...)
 }
 
-func newSvrTransHandler(opt *remote.ServerOption) (*svrTransHandler, error) {
-	return &svrTransHandler{
+func newSvrTransHandler(opt *remote.ServerOption, opts ...Option) (*svrTransHandler, error) {
+	t := &svrTransHandler{
 		opt:     opt,
 		svcInfo: opt.SvcInfo,
 		codec:   protobuf.NewGRPCCodec(),
-	}, nil
+	}
+	for _, o := range opts {
+		o(t)
+	}
+	if t.reflectionRegistry != nil && t.svcInfo != nil {
+		// make the server's own service discoverable without the user
+		// having to RegisterService it into reg themselves.
+		t.reflectionRegistry.RegisterService(t.svcInfo.ServiceName, t.svcInfo)
+	}
+	t.chainedUnary = chainUnaryInterceptors(t.unaryInterceptors)
+	t.chainedStream = chainStreamInterceptors(t.streamInterceptors)
+	return t, nil
 }
 
 var _ remote.ServerTransHandler = &svrTransHandler{}
@@ -65,6 +109,22 @@ type svrTransHandler struct {
 	svcInfo    *serviceinfo.ServiceInfo
 	inkHdlFunc endpoint.Endpoint
 	codec      remote.Codec
+	healthSvr  *health.Server
+
+	reflectionRegistry *reflection.Registry
+
+	enableGRPCWeb bool
+
+	unaryInterceptors  []UnaryServerInterceptor
+	streamInterceptors []StreamServerInterceptor
+	chainedUnary       UnaryServerInterceptor
+	chainedStream      StreamServerInterceptor
+
+	panicHandler       PanicHandler
+	panicStackInStatus bool
+
+	keepaliveParams            KeepaliveParams
+	keepaliveEnforcementPolicy KeepaliveEnforcementPolicy
 }
 
 func (t *svrTransHandler) Write(ctx context.Context, conn net.Conn, msg remote.Message) (err error) {
@@ -86,7 +146,15 @@ func (t *svrTransHandler) Read(ctx context.Context, conn net.Conn, msg remote.Me
 
 // 只 return write err
 func (t *svrTransHandler) OnRead(ctx context.Context, conn net.Conn) error {
-	tr, err := grpcTransport.NewServerTransport(ctx, conn.(netpoll.Connection))
+	opts := t.keepaliveTransportOptions()
+	if t.enableGRPCWeb {
+		if contentType, ok := peekContentType(conn.(netpoll.Connection)); ok {
+			if isWeb, isText := isGRPCWebContentType(contentType); isWeb {
+				opts = append(opts, grpcTransport.WithGRPCWeb(isText))
+			}
+		}
+	}
+	tr, err := grpcTransport.NewServerTransport(ctx, conn.(netpoll.Connection), opts...)
 	if err != nil {
 		return err
 	}
@@ -114,6 +182,11 @@ func (t *svrTransHandler) OnRead(ctx context.Context, conn net.Conn) error {
 					} else {
 						t.opt.Logger.Errorf("KITEX: panic happened, %v\n%s", panicErr, string(debug.Stack()))
 					}
+					ph := t.panicHandler
+					if ph == nil {
+						ph = t.defaultPanicHandler
+					}
+					tr.WriteStatus(s, ph(ctx, panicErr))
 				}
 				t.finishTracer(ctx, ri, err, panicErr)
 			}()
@@ -141,8 +214,55 @@ func (t *svrTransHandler) OnRead(ctx context.Context, conn net.Conn) error {
 			ink.SetServiceName(sm[idx+1 : pos])
 
 			st := NewStream(ctx, t.svcInfo, newServerConn(tr, s), t)
-			if err := t.inkHdlFunc(ctx, &streaming.Args{Stream: st}, nil); err != nil {
-				tr.WriteStatus(s, convertFromKitexToGrpc(err))
+
+			// FullMethod keeps the leading "/" to match grpc-go's
+			// UnaryServerInfo/StreamServerInfo, so ported grpc-go
+			// middleware that splits on "/" works unmodified.
+			info := &ServerInfo{FullMethod: "/" + sm}
+			var callHandler func(ctx context.Context, req interface{}) (interface{}, error)
+
+			switch {
+			case t.healthSvr != nil && sm[:pos] == healthCheckServiceName:
+				method := sm[pos+1:]
+				info.IsServerStream = method == "Watch"
+				callHandler = func(ctx context.Context, req interface{}) (interface{}, error) {
+					return nil, t.serveHealthCheck(ctx, method, st)
+				}
+			case t.reflectionRegistry != nil && sm[:pos] == reflection.ServiceName && sm[pos+1:] == serverReflectionMethodName:
+				info.IsClientStream, info.IsServerStream = true, true
+				callHandler = func(ctx context.Context, req interface{}) (interface{}, error) {
+					return nil, t.reflectionRegistry.ServerReflectionInfo(st)
+				}
+			default:
+				if t.svcInfo != nil {
+					if mi := t.svcInfo.MethodInfo(sm[pos+1:]); mi != nil {
+						info.MethodInfo = mi
+						switch mi.StreamingMode() {
+						case serviceinfo.StreamingClient:
+							info.IsClientStream = true
+						case serviceinfo.StreamingServer:
+							info.IsServerStream = true
+						case serviceinfo.StreamingBidirectional:
+							info.IsClientStream, info.IsServerStream = true, true
+						}
+					}
+				}
+				callHandler = func(ctx context.Context, req interface{}) (interface{}, error) {
+					return nil, t.inkHdlFunc(ctx, req, nil)
+				}
+			}
+
+			var callErr error
+			switch {
+			case (info.IsClientStream || info.IsServerStream) && t.chainedStream != nil:
+				_, callErr = t.chainedStream(ctx, &streaming.Args{Stream: st}, info, callHandler)
+			case !info.IsClientStream && !info.IsServerStream && t.chainedUnary != nil:
+				_, callErr = t.chainedUnary(ctx, &streaming.Args{Stream: st}, info, callHandler)
+			default:
+				_, callErr = callHandler(ctx, &streaming.Args{Stream: st})
+			}
+			if callErr != nil {
+				tr.WriteStatus(s, convertFromKitexToGrpc(callErr))
 				return
 			}
 			tr.WriteStatus(s, status.New(codes.OK, ""))
@@ -204,3 +324,25 @@ func (t *svrTransHandler) finishTracer(ctx context.Context, ri rpcinfo.RPCInfo,
 	t.opt.TracerCtl.DoFinish(ctx, ri, err, t.opt.Logger)
 	rpcStats.Reset()
 }
+
+// serveHealthCheck answers the grpc.health.v1.Health service's Check and
+// Watch methods directly from t.healthSvr, without involving t.inkHdlFunc or
+// the user's registered service.
+func (t *svrTransHandler) serveHealthCheck(ctx context.Context, method string, st *Stream) error {
+	req := new(health.HealthCheckRequest)
+	if err := st.RecvMsg(req); err != nil {
+		return err
+	}
+	switch method {
+	case "Check":
+		resp, err := t.healthSvr.Check(ctx, req)
+		if err != nil {
+			return err
+		}
+		return st.SendMsg(resp)
+	case "Watch":
+		return t.healthSvr.Watch(ctx, req, st)
+	default:
+		return status.New(codes.Unimplemented, fmt.Sprintf("unknown Health method %q", method)).Err()
+	}
+}